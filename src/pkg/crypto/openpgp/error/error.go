@@ -0,0 +1,70 @@
+// Copyright 2011 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package error contains errors used by the OpenPGP packages.
+package error
+
+import (
+	"os"
+	"strconv"
+)
+
+// A StructuralError is returned when OpenPGP data is found to be syntactically
+// invalid.
+type StructuralError string
+
+func (s StructuralError) String() string {
+	return "openpgp: invalid data: " + string(s)
+}
+
+// UnsupportedError indicates that, although the OpenPGP data is valid, it
+// makes use of currently unimplemented features.
+type UnsupportedError string
+
+func (s UnsupportedError) String() string {
+	return "openpgp: unsupported feature: " + string(s)
+}
+
+// InvalidArgumentError indicates that the caller is in error and passed an
+// incorrect value.
+type InvalidArgumentError string
+
+func (i InvalidArgumentError) String() string {
+	return "openpgp: invalid argument: " + string(i)
+}
+
+// SignatureError is returned when a signature check fails.
+type SignatureError string
+
+func (b SignatureError) String() string {
+	return "openpgp: invalid signature: " + string(b)
+}
+
+type keyIncorrectError int
+
+func (keyIncorrectError) String() string {
+	return "openpgp: incorrect key"
+}
+
+// KeyIncorrectError is returned when a decryption operation fails because the
+// key is incorrect.
+var KeyIncorrectError os.Error = keyIncorrectError(0)
+
+type unknownIssuerError int
+
+func (unknownIssuerError) String() string {
+	return "openpgp: signature made by unknown entity"
+}
+
+// UnknownIssuerError is returned when a signature is made by an entity that
+// is not found in a given KeyRing.
+var UnknownIssuerError os.Error = unknownIssuerError(0)
+
+// UnknownPacketTypeError is returned for packet types that we don't know how
+// to process, but that are recoverable (the packet can simply be skipped).
+type UnknownPacketTypeError uint8
+
+func (upte UnknownPacketTypeError) String() string {
+	return "openpgp: unknown packet type: " + strconv.Itoa(int(upte))
+}
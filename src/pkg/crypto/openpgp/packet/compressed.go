@@ -0,0 +1,50 @@
+// Copyright 2011 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package packet
+
+import (
+	"compress/bzip2"
+	"compress/flate"
+	"compress/zlib"
+	"crypto/openpgp/error"
+	"io"
+	"os"
+	"strconv"
+)
+
+// Compressed represents a compressed OpenPGP packet. The decompressed
+// contents will contain more OpenPGP packets. See RFC 4880, section 5.6.
+type Compressed struct {
+	Body io.Reader
+}
+
+// Compression algorithm ids, as specified in RFC 4880, section 9.3.
+const (
+	compressionUncompressed = 0
+	compressionZIP          = 1
+	compressionZLIB         = 2
+	compressionBZIP2        = 3
+)
+
+func (c *Compressed) parse(r io.Reader) (err os.Error) {
+	var buf [1]byte
+	if _, err = readFull(r, buf[:]); err != nil {
+		return
+	}
+
+	switch buf[0] {
+	case compressionUncompressed:
+		c.Body = r
+	case compressionZIP:
+		c.Body = flate.NewReader(r)
+	case compressionZLIB:
+		c.Body, err = zlib.NewReader(r)
+	case compressionBZIP2:
+		c.Body = bzip2.NewReader(r)
+	default:
+		err = error.UnsupportedError("unknown compression algorithm: " + strconv.Itoa(int(buf[0])))
+	}
+	return
+}
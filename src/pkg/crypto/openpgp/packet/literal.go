@@ -0,0 +1,93 @@
+// Copyright 2011 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package packet
+
+import (
+	"crypto/openpgp/error"
+	"io"
+	"os"
+)
+
+// LiteralData represents an encrypted file, as specified by RFC 4880,
+// section 5.9.
+type LiteralData struct {
+	IsBinary bool
+	FileName string
+	Time     uint32 // Unix epoch time, either creation or modification. 0 means undefined.
+	Body     io.Reader
+}
+
+func (l *LiteralData) parse(r io.Reader) (err os.Error) {
+	var buf [4]byte
+
+	if _, err = readFull(r, buf[:1]); err != nil {
+		return
+	}
+	switch buf[0] {
+	case 'b':
+		l.IsBinary = true
+	case 't', 'u':
+		l.IsBinary = false
+	default:
+		return error.UnsupportedError("unsupported literal data type byte: " + string(buf[0]))
+	}
+
+	if _, err = readFull(r, buf[:1]); err != nil {
+		return
+	}
+	fileName := make([]byte, buf[0])
+	if _, err = readFull(r, fileName); err != nil {
+		return
+	}
+	l.FileName = string(fileName)
+
+	if _, err = readFull(r, buf[:4]); err != nil {
+		return
+	}
+	l.Time = uint32(buf[0])<<24 | uint32(buf[1])<<16 | uint32(buf[2])<<8 | uint32(buf[3])
+	l.Body = r
+	return
+}
+
+// SerializeLiteral serializes a literal data packet to w and returns a
+// WriteCloser to which the literal data itself should be written. The
+// packet is written using partial-length framing (see RFC 4880, section
+// 4.2.2.4) so the caller need not know the size of the data in advance; the
+// returned WriteCloser must be closed once all of the data has been
+// written.
+func SerializeLiteral(w io.Writer, isBinary bool, fileName string, time uint32) (contents io.WriteCloser, err os.Error) {
+	var buf [4]byte
+	buf[0] = 't'
+	if isBinary {
+		buf[0] = 'b'
+	}
+	if len(fileName) > 255 {
+		fileName = fileName[:255]
+	}
+	buf[1] = byte(len(fileName))
+
+	inner, err := serializeStreamHeader(w, packetTypeLiteralData)
+	if err != nil {
+		return
+	}
+
+	if _, err = inner.Write(buf[:2]); err != nil {
+		return
+	}
+	if _, err = inner.Write([]byte(fileName)); err != nil {
+		return
+	}
+
+	buf[0] = byte(time >> 24)
+	buf[1] = byte(time >> 16)
+	buf[2] = byte(time >> 8)
+	buf[3] = byte(time)
+	if _, err = inner.Write(buf[:4]); err != nil {
+		return
+	}
+
+	contents = inner
+	return
+}
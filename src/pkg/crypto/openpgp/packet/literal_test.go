@@ -0,0 +1,52 @@
+// Copyright 2011 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package packet
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestLiteralDataRoundTrip(t *testing.T) {
+	contents := []byte("the quick brown fox jumps over the lazy dog")
+
+	buf := bytes.NewBuffer(nil)
+	w, err := SerializeLiteral(buf, true, "test.txt", 1234567890)
+	if err != nil {
+		t.Fatalf("SerializeLiteral: %s", err)
+	}
+	if _, err := w.Write(contents); err != nil {
+		t.Fatalf("Write: %s", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %s", err)
+	}
+
+	p, err := Read(buf)
+	if err != nil {
+		t.Fatalf("Read: %s", err)
+	}
+	l, ok := p.(*LiteralData)
+	if !ok {
+		t.Fatalf("Read returned %T, not *LiteralData", p)
+	}
+	if !l.IsBinary {
+		t.Fatalf("IsBinary = false, want true")
+	}
+	if l.FileName != "test.txt" {
+		t.Fatalf("FileName = %q, want %q", l.FileName, "test.txt")
+	}
+	if l.Time != 1234567890 {
+		t.Fatalf("Time = %d, want %d", l.Time, 1234567890)
+	}
+	got, err := ioutil.ReadAll(l.Body)
+	if err != nil {
+		t.Fatalf("reading body: %s", err)
+	}
+	if !bytes.Equal(got, contents) {
+		t.Fatalf("body = %q, want %q", got, contents)
+	}
+}
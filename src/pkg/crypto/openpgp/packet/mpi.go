@@ -0,0 +1,42 @@
+// Copyright 2011 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package packet
+
+import (
+	"big"
+	"io"
+	"os"
+)
+
+// readMPI reads a big-endian, multi-precision integer from r, as specified
+// in RFC 4880, section 3.2.
+func readMPI(r io.Reader) (mpi []byte, err os.Error) {
+	var buf [2]byte
+	if _, err = readFull(r, buf[:]); err != nil {
+		return
+	}
+	numBits := int(buf[0])<<8 | int(buf[1])
+	numBytes := (numBits + 7) / 8
+	mpi = make([]byte, numBytes)
+	_, err = readFull(r, mpi)
+	return
+}
+
+// mpiLength returns the number of bytes that n occupies when serialized as
+// an MPI.
+func mpiLength(n *big.Int) int {
+	return 2 /* bit length */ + (n.BitLen()+7)/8
+}
+
+// writeMPI serializes n to w, as specified in RFC 4880, section 3.2.
+func writeMPI(w io.Writer, n *big.Int) (err os.Error) {
+	bitLength := n.BitLen()
+	_, err = w.Write([]byte{byte(bitLength >> 8), byte(bitLength)})
+	if err != nil {
+		return
+	}
+	_, err = w.Write(n.Bytes())
+	return
+}
@@ -0,0 +1,52 @@
+// Copyright 2011 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package packet
+
+import (
+	"crypto/openpgp/error"
+	"crypto/openpgp/s2k"
+	"hash"
+	"io"
+	"os"
+	"strconv"
+)
+
+// OnePassSignature represents a one-pass signature packet. It precedes the
+// literal data that it signs, so that a verifier can start hashing the data
+// as it's read, rather than having to buffer it until the trailing
+// Signature packet is reached. See RFC 4880, section 5.4.
+type OnePassSignature struct {
+	SigType    SignatureType
+	Hash       func() hash.Hash
+	HashFunc   uint8
+	PubKeyAlgo PublicKeyAlgorithm
+	KeyId      uint64
+	IsLast     bool
+}
+
+const onePassSignatureVersion = 3
+
+func (ops *OnePassSignature) parse(r io.Reader) (err os.Error) {
+	var buf [13]byte
+	if _, err = readFull(r, buf[:]); err != nil {
+		return
+	}
+	if buf[0] != onePassSignatureVersion {
+		return error.UnsupportedError("one-pass signature packet version " + strconv.Itoa(int(buf[0])))
+	}
+
+	var ok bool
+	ops.Hash, ok = s2k.HashIdToHash(buf[2])
+	if !ok {
+		return error.UnsupportedError("hash function: " + strconv.Itoa(int(buf[2])))
+	}
+	ops.HashFunc = buf[2]
+	ops.SigType = SignatureType(buf[1])
+	ops.PubKeyAlgo = PublicKeyAlgorithm(buf[3])
+	ops.KeyId = uint64(buf[4])<<56 | uint64(buf[5])<<48 | uint64(buf[6])<<40 | uint64(buf[7])<<32 |
+		uint64(buf[8])<<24 | uint64(buf[9])<<16 | uint64(buf[10])<<8 | uint64(buf[11])
+	ops.IsLast = buf[12] != 0
+	return
+}
@@ -0,0 +1,377 @@
+// Copyright 2011 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package packet implements parsing and serialization of OpenPGP packets, as
+// specified in RFC 4880.
+package packet
+
+import (
+	"crypto/openpgp/error"
+	"io"
+	"os"
+)
+
+// readFull is the same as io.ReadFull except that it returns
+// io.ErrUnexpectedEOF instead of os.EOF when less than len(buf) bytes are
+// read.
+func readFull(r io.Reader, buf []byte) (n int, err os.Error) {
+	n, err = io.ReadFull(r, buf)
+	if err == os.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return
+}
+
+// packetType represents the numeric ids of the different OpenPGP packet
+// types. See RFC 4880, section 4.3.
+type packetType uint8
+
+const (
+	packetTypePublicKeyEncryptedKey     packetType = 1
+	packetTypeSignature                 packetType = 2
+	packetTypeSymmetricKeyEncrypted     packetType = 3
+	packetTypeOnePassSignature          packetType = 4
+	packetTypePrivateKey                packetType = 5
+	packetTypePublicKey                 packetType = 6
+	packetTypePrivateSubkey             packetType = 7
+	packetTypeCompressed                packetType = 8
+	packetTypeSymmetricallyEncrypted    packetType = 9
+	packetTypeLiteralData               packetType = 11
+	packetTypePublicSubkey              packetType = 14
+	packetTypeSymmetricallyEncryptedMDC packetType = 18
+)
+
+// Packet represents an OpenPGP packet. Users of this package typically want
+// the packet-specific methods and not this interface.
+type Packet interface {
+	parse(r io.Reader) os.Error
+}
+
+// consumeAll reads from r until EOF, discarding the result.
+func consumeAll(r io.Reader) (err os.Error) {
+	var buf [1024]byte
+	for {
+		_, err = r.Read(buf[:])
+		if err == os.EOF {
+			return nil
+		}
+		if err != nil {
+			return
+		}
+	}
+	panic("unreachable")
+}
+
+// Read reads a single OpenPGP packet from r and returns it as a Packet
+// interface value. The type of the returned value indicates the specific
+// type of packet that was read. If the packet type is not supported by this
+// package, an UnknownPacketTypeError is returned, but r is positioned just
+// after the unknown packet so that reading may continue.
+func Read(r io.Reader) (p Packet, err os.Error) {
+	tag, _, contents, err := readHeader(r)
+	if err != nil {
+		return
+	}
+
+	switch tag {
+	case packetTypePublicKeyEncryptedKey:
+		p = new(PublicKeyEncrypted)
+	case packetTypeSymmetricKeyEncrypted:
+		p = new(SymmetricKeyEncrypted)
+	case packetTypeSymmetricallyEncrypted:
+		p = &SymmetricallyEncrypted{MDC: false}
+	case packetTypeSymmetricallyEncryptedMDC:
+		p = &SymmetricallyEncrypted{MDC: true}
+	case packetTypePublicKey:
+		p = new(PublicKey)
+	case packetTypePublicSubkey:
+		p = new(PublicKey)
+	case packetTypePrivateKey:
+		p = new(PrivateKey)
+	case packetTypePrivateSubkey:
+		p = new(PrivateKey)
+	case packetTypeCompressed:
+		p = new(Compressed)
+	case packetTypeLiteralData:
+		p = new(LiteralData)
+	case packetTypeSignature:
+		p = new(Signature)
+	case packetTypeOnePassSignature:
+		p = new(OnePassSignature)
+	default:
+		err = consumeAll(contents)
+		if err != nil {
+			return
+		}
+		return nil, error.UnknownPacketTypeError(tag)
+	}
+
+	err = p.parse(contents)
+	if err != nil {
+		consumeAll(contents)
+	}
+	return
+}
+
+// readHeader reads the next packet tag and length from reader.
+func readHeader(r io.Reader) (tag packetType, length int64, contents io.Reader, err os.Error) {
+	var buf [4]byte
+	_, err = io.ReadFull(r, buf[:1])
+	if err != nil {
+		return
+	}
+	if buf[0]&0x80 == 0 {
+		err = error.StructuralError("tag byte does not have MSB set")
+		return
+	}
+	if buf[0]&0x40 == 0 {
+		// Old format packet
+		tag = packetType((buf[0] & 0x3f) >> 2)
+		lengthType := buf[0] & 3
+		if lengthType == 3 {
+			length = -1
+			contents = r
+			return
+		}
+		lengthBytes := 1 << lengthType
+		_, err = readFull(r, buf[0:lengthBytes])
+		if err != nil {
+			return
+		}
+		for i := 0; i < lengthBytes; i++ {
+			length <<= 8
+			length |= int64(buf[i])
+		}
+		contents = &spanReader{r, length}
+		return
+	}
+
+	// New format packet
+	tag = packetType(buf[0] & 0x3f)
+	length, isPartial, err := readLength(r)
+	if err != nil {
+		return
+	}
+	if isPartial {
+		contents = &partialLengthReader{
+			remaining: length,
+			isPartial: true,
+			r:         r,
+		}
+		length = -1
+	} else {
+		contents = &spanReader{r, length}
+	}
+	return
+}
+
+// readLength reads an OpenPGP length, as specified in RFC 4880, section
+// 4.2.2.
+func readLength(r io.Reader) (length int64, isPartial bool, err os.Error) {
+	var buf [4]byte
+	_, err = readFull(r, buf[:1])
+	if err != nil {
+		return
+	}
+	switch {
+	case buf[0] < 192:
+		length = int64(buf[0])
+	case buf[0] < 224:
+		length = int64(buf[0]-192) << 8
+		_, err = readFull(r, buf[0:1])
+		if err != nil {
+			return
+		}
+		length += int64(buf[0]) + 192
+	case buf[0] < 255:
+		length = int64(1) << (buf[0] & 0x1f)
+		isPartial = true
+	default:
+		_, err = readFull(r, buf[0:4])
+		if err != nil {
+			return
+		}
+		length = int64(buf[0])<<24 |
+			int64(buf[1])<<16 |
+			int64(buf[2])<<8 |
+			int64(buf[3])
+	}
+	return
+}
+
+// partialLengthReader wraps an io.Reader and handles the chaining of
+// partial-length packets, as specified in RFC 4880, section 4.2.2.4.
+type partialLengthReader struct {
+	r         io.Reader
+	remaining int64
+	isPartial bool
+}
+
+func (r *partialLengthReader) Read(p []byte) (n int, err os.Error) {
+	for r.remaining == 0 {
+		if !r.isPartial {
+			return 0, os.EOF
+		}
+		r.remaining, r.isPartial, err = readLength(r.r)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	toRead := int64(len(p))
+	if toRead > r.remaining {
+		toRead = r.remaining
+	}
+
+	n, err = r.r.Read(p[:toRead])
+	r.remaining -= int64(n)
+	if n < int(toRead) && err == os.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return
+}
+
+// spanReader is like io.LimitReader, but returns os.EOF, rather than an
+// io.EOF-shaped error, once the limit is reached.
+type spanReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *spanReader) Read(p []byte) (n int, err os.Error) {
+	if l.n <= 0 {
+		return 0, os.EOF
+	}
+	if int64(len(p)) > l.n {
+		p = p[0:l.n]
+	}
+	n, err = l.r.Read(p)
+	l.n -= int64(n)
+	if l.n <= 0 && err == nil {
+		err = os.EOF
+	}
+	return
+}
+
+// partialLengthWriter writes a stream of data using OpenPGP partial lengths.
+// See RFC 4880, section 4.2.2.4.
+type partialLengthWriter struct {
+	w          io.Writer
+	buf        [1024]byte
+	used       int
+	lengthByte [1]byte
+}
+
+func (w *partialLengthWriter) Write(p []byte) (n int, err os.Error) {
+	bufLen := len(w.buf)
+	for len(p) > 0 {
+		space := bufLen - w.used
+		if space == 0 {
+			if err = w.flush(false); err != nil {
+				return
+			}
+			space = bufLen
+		}
+		toCopy := len(p)
+		if toCopy > space {
+			toCopy = space
+		}
+		copy(w.buf[w.used:], p[:toCopy])
+		w.used += toCopy
+		p = p[toCopy:]
+		n += toCopy
+	}
+	return
+}
+
+// flush writes out the currently buffered data as a partial-length chunk. If
+// last is true, the final (non-partial) length chunk is written instead.
+func (w *partialLengthWriter) flush(last bool) (err os.Error) {
+	if last {
+		if err = serializeLength(w.w, w.used); err != nil {
+			return
+		}
+		_, err = w.w.Write(w.buf[:w.used])
+		w.used = 0
+		return
+	}
+
+	// The exponent of the largest power of two that is <= w.used.
+	var power uint
+	for (1 << (power + 1)) <= w.used {
+		power++
+	}
+	toWrite := 1 << power
+	w.lengthByte[0] = 224 + byte(power)
+	if _, err = w.w.Write(w.lengthByte[:]); err != nil {
+		return
+	}
+	if _, err = w.w.Write(w.buf[:toWrite]); err != nil {
+		return
+	}
+	remaining := w.used - toWrite
+	copy(w.buf[:], w.buf[toWrite:w.used])
+	w.used = remaining
+	return
+}
+
+func (w *partialLengthWriter) Close() (err os.Error) {
+	for w.used > 1<<15 {
+		if err = w.flush(false); err != nil {
+			return
+		}
+	}
+	return w.flush(true)
+}
+
+// serializeLength writes an OpenPGP length to w, as specified in RFC 4880,
+// section 4.2.2.
+func serializeLength(w io.Writer, length int) (err os.Error) {
+	var buf [5]byte
+	var n int
+
+	if length < 192 {
+		buf[0] = byte(length)
+		n = 1
+	} else if length < 8384 {
+		length -= 192
+		buf[0] = 192 + byte(length>>8)
+		buf[1] = byte(length)
+		n = 2
+	} else {
+		buf[0] = 255
+		buf[1] = byte(length >> 24)
+		buf[2] = byte(length >> 16)
+		buf[3] = byte(length >> 8)
+		buf[4] = byte(length)
+		n = 5
+	}
+
+	_, err = w.Write(buf[:n])
+	return
+}
+
+// serializeHeader writes an OpenPGP packet header to w, with a fixed, known
+// length, using the new format. See RFC 4880, section 4.2.
+func serializeHeader(w io.Writer, ptype packetType, length int) (err os.Error) {
+	var buf [1]byte
+	buf[0] = 0x80 | 0x40 | byte(ptype)
+	if _, err = w.Write(buf[:]); err != nil {
+		return
+	}
+	return serializeLength(w, length)
+}
+
+// serializeStreamHeader writes an OpenPGP packet header to w where the
+// length of the contents is not known in advance. The returned WriteCloser
+// must be closed to write the last chunk of data, which carries a
+// non-partial length.
+func serializeStreamHeader(w io.Writer, ptype packetType) (out io.WriteCloser, err os.Error) {
+	var buf [1]byte
+	buf[0] = 0x80 | 0x40 | byte(ptype)
+	if _, err = w.Write(buf[:]); err != nil {
+		return
+	}
+	return &partialLengthWriter{w: w}, nil
+}
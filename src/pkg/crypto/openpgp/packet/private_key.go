@@ -0,0 +1,203 @@
+// Copyright 2011 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package packet
+
+import (
+	"big"
+	"bytes"
+	"crypto/cipher"
+	"crypto/dsa"
+	"crypto/openpgp/error"
+	"crypto/openpgp/s2k"
+	"crypto/rsa"
+	"crypto/sha1"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+)
+
+// PrivateKey represents a possibly encrypted private key. See RFC 4880,
+// section 5.5.3.
+type PrivateKey struct {
+	PublicKey
+	Encrypted     bool // if true then the private key is unavailable until Decrypt has been called.
+	encryptedData []byte
+	cipher        CipherFunction
+	s2k           func(out, in []byte)
+	PrivateKey    interface{} // An *rsa.PrivateKey or *dsa.PrivateKey.
+	iv            []byte
+	sha1Checksum  bool
+}
+
+func (pk *PrivateKey) parse(r io.Reader) (err os.Error) {
+	err = pk.PublicKey.parse(r)
+	if err != nil {
+		return
+	}
+
+	var buf [1]byte
+	if _, err = readFull(r, buf[:]); err != nil {
+		return
+	}
+
+	switch buf[0] {
+	case 0:
+		pk.s2k = nil
+		pk.Encrypted = false
+	case 254, 255:
+		pk.sha1Checksum = buf[0] == 254
+		if _, err = readFull(r, buf[:]); err != nil {
+			return
+		}
+		pk.cipher = CipherFunction(buf[0])
+		if pk.cipher.keySize() == 0 {
+			return error.UnsupportedError("unknown cipher: " + strconv.Itoa(int(pk.cipher)))
+		}
+		if pk.s2k, err = s2k.Parse(r); err != nil {
+			return
+		}
+		pk.Encrypted = true
+	default:
+		return error.UnsupportedError("deprecated private key encryption scheme")
+	}
+
+	if pk.Encrypted {
+		blockSize := pk.cipher.blockSize()
+		if blockSize == 0 {
+			return error.UnsupportedError("unsupported cipher in private key: " + strconv.Itoa(int(pk.cipher)))
+		}
+		pk.iv = make([]byte, blockSize)
+		if _, err = readFull(r, pk.iv); err != nil {
+			return
+		}
+	}
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return
+	}
+
+	if pk.Encrypted {
+		pk.encryptedData = data
+		return nil
+	}
+
+	return pk.parsePrivateKey(data)
+}
+
+// Decrypt decrypts an encrypted private key using the given passphrase.
+func (pk *PrivateKey) Decrypt(passphrase []byte) os.Error {
+	if !pk.Encrypted {
+		return nil
+	}
+
+	key := make([]byte, pk.cipher.keySize())
+	pk.s2k(key, passphrase)
+	block := pk.cipher.new(key)
+	cfb := cipher.NewCFBDecrypter(block, pk.iv)
+
+	data := make([]byte, len(pk.encryptedData))
+	cfb.XORKeyStream(data, pk.encryptedData)
+
+	if err := pk.parsePrivateKey(data); err != nil {
+		if _, ok := err.(error.StructuralError); ok {
+			return error.StructuralError("private key checksum failure (incorrect passphrase?)")
+		}
+		return err
+	}
+	pk.Encrypted = false
+	pk.encryptedData = nil
+	return nil
+}
+
+// parsePrivateKey parses the private key material, which is the remaining
+// MPIs of the packet followed by either a two-byte checksum or a SHA-1 hash
+// of the preceding bytes, and verifies it. See RFC 4880, section 5.5.3.
+func (pk *PrivateKey) parsePrivateKey(data []byte) (err os.Error) {
+	checksumLength := 2
+	if pk.sha1Checksum {
+		checksumLength = sha1.Size
+	}
+	if len(data) < checksumLength {
+		return error.StructuralError("truncated private key data")
+	}
+	material, checksum := data[:len(data)-checksumLength], data[len(data)-checksumLength:]
+
+	if pk.sha1Checksum {
+		h := sha1.New()
+		h.Write(material)
+		if !bytes.Equal(h.Sum(), checksum) {
+			return error.StructuralError("private key checksum failure")
+		}
+	} else {
+		var sum uint16
+		for _, b := range material {
+			sum += uint16(b)
+		}
+		if byte(sum>>8) != checksum[0] || byte(sum) != checksum[1] {
+			return error.StructuralError("private key checksum failure")
+		}
+	}
+
+	buf := bytes.NewBuffer(material)
+	switch pk.PubKeyAlgo {
+	case PubKeyAlgoRSA, PubKeyAlgoRSASignOnly, PubKeyAlgoRSAEncryptOnly:
+		return pk.parseRSAPrivateKey(buf)
+	case PubKeyAlgoDSA:
+		return pk.parseDSAPrivateKey(buf)
+	}
+	panic("unreachable")
+}
+
+func (pk *PrivateKey) parseRSAPrivateKey(r io.Reader) (err os.Error) {
+	dBytes, err := readMPI(r)
+	if err != nil {
+		return
+	}
+	pBytes, err := readMPI(r)
+	if err != nil {
+		return
+	}
+	qBytes, err := readMPI(r)
+	if err != nil {
+		return
+	}
+
+	priv := new(rsa.PrivateKey)
+	priv.PublicKey = rsa.PublicKey{
+		N: pk.n,
+		E: int(pk.e.Int64()),
+	}
+	priv.D = new(big.Int).SetBytes(dBytes)
+	priv.P = new(big.Int).SetBytes(pBytes)
+	priv.Q = new(big.Int).SetBytes(qBytes)
+	if err = priv.Validate(); err != nil {
+		return error.StructuralError(err.String())
+	}
+	priv.Precompute()
+	pk.PrivateKey = priv
+	return nil
+}
+
+func (pk *PrivateKey) parseDSAPrivateKey(r io.Reader) (err os.Error) {
+	xBytes, err := readMPI(r)
+	if err != nil {
+		return
+	}
+
+	priv := new(dsa.PrivateKey)
+	priv.PublicKey = dsa.PublicKey{
+		Parameters: dsa.Parameters{
+			P: pk.p,
+			Q: pk.q,
+			G: pk.g,
+		},
+		Y: pk.y,
+	}
+	priv.X = new(big.Int).SetBytes(xBytes)
+	pk.PrivateKey = priv
+	return nil
+}
@@ -0,0 +1,141 @@
+// Copyright 2011 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package packet
+
+import (
+	"big"
+	"bytes"
+	"crypto/openpgp/error"
+	"crypto/sha1"
+	"io"
+	"os"
+	"strconv"
+)
+
+// PublicKeyAlgorithm represents the different public key system specified
+// for OpenPGP. See RFC 4880, section 9.1.
+type PublicKeyAlgorithm uint8
+
+const (
+	PubKeyAlgoRSA            PublicKeyAlgorithm = 1
+	PubKeyAlgoRSAEncryptOnly PublicKeyAlgorithm = 2
+	PubKeyAlgoRSASignOnly    PublicKeyAlgorithm = 3
+	PubKeyAlgoElGamal        PublicKeyAlgorithm = 16
+	PubKeyAlgoDSA            PublicKeyAlgorithm = 17
+)
+
+// CanSign returns true if the algorithm can be used for signing, as opposed
+// to encryption only.
+func (pka PublicKeyAlgorithm) CanSign() bool {
+	switch pka {
+	case PubKeyAlgoRSA, PubKeyAlgoRSASignOnly, PubKeyAlgoDSA:
+		return true
+	}
+	return false
+}
+
+const publicKeyVersion = 4
+
+// PublicKey represents an OpenPGP public key. See RFC 4880, section 5.5.2.
+type PublicKey struct {
+	CreationTime uint32
+	PubKeyAlgo   PublicKeyAlgorithm
+	KeyId        uint64
+
+	// RSA public key fields
+	n, e *big.Int
+
+	// DSA public key fields
+	p, q, g, y *big.Int
+}
+
+func (pk *PublicKey) parse(r io.Reader) (err os.Error) {
+	body := new(bytes.Buffer)
+	tee := io.TeeReader(r, body)
+
+	var header [6]byte
+	if _, err = readFull(tee, header[:]); err != nil {
+		return
+	}
+	if header[0] != publicKeyVersion {
+		return error.UnsupportedError("public key version " + strconv.Itoa(int(header[0])))
+	}
+	pk.CreationTime = uint32(header[1])<<24 | uint32(header[2])<<16 | uint32(header[3])<<8 | uint32(header[4])
+	pk.PubKeyAlgo = PublicKeyAlgorithm(header[5])
+
+	switch pk.PubKeyAlgo {
+	case PubKeyAlgoRSA, PubKeyAlgoRSAEncryptOnly, PubKeyAlgoRSASignOnly:
+		err = pk.parseRSA(tee)
+	case PubKeyAlgoDSA:
+		err = pk.parseDSA(tee)
+	default:
+		err = error.UnsupportedError("public key type: " + strconv.Itoa(int(pk.PubKeyAlgo)))
+	}
+	if err != nil {
+		return
+	}
+
+	pk.setKeyId(body.Bytes())
+	return
+}
+
+// setKeyId computes the key id from the serialized, version-4 public key
+// body (everything but the packet header), as specified in RFC 4880,
+// section 12.2.
+func (pk *PublicKey) setKeyId(body []byte) {
+	h := sha1.New()
+	h.Write([]byte{0x99, byte(len(body) >> 8), byte(len(body))})
+	h.Write(body)
+	fingerprint := h.Sum()
+	pk.KeyId = uint64(fingerprint[12])<<56 |
+		uint64(fingerprint[13])<<48 |
+		uint64(fingerprint[14])<<40 |
+		uint64(fingerprint[15])<<32 |
+		uint64(fingerprint[16])<<24 |
+		uint64(fingerprint[17])<<16 |
+		uint64(fingerprint[18])<<8 |
+		uint64(fingerprint[19])
+}
+
+func (pk *PublicKey) parseRSA(r io.Reader) (err os.Error) {
+	nBytes, err := readMPI(r)
+	if err != nil {
+		return
+	}
+	eBytes, err := readMPI(r)
+	if err != nil {
+		return
+	}
+	pk.n = new(big.Int).SetBytes(nBytes)
+	pk.e = new(big.Int).SetBytes(eBytes)
+	if pk.e.BitLen() > 32 {
+		return error.UnsupportedError("large public exponent")
+	}
+	return nil
+}
+
+func (pk *PublicKey) parseDSA(r io.Reader) (err os.Error) {
+	pBytes, err := readMPI(r)
+	if err != nil {
+		return
+	}
+	qBytes, err := readMPI(r)
+	if err != nil {
+		return
+	}
+	gBytes, err := readMPI(r)
+	if err != nil {
+		return
+	}
+	yBytes, err := readMPI(r)
+	if err != nil {
+		return
+	}
+	pk.p = new(big.Int).SetBytes(pBytes)
+	pk.q = new(big.Int).SetBytes(qBytes)
+	pk.g = new(big.Int).SetBytes(gBytes)
+	pk.y = new(big.Int).SetBytes(yBytes)
+	return nil
+}
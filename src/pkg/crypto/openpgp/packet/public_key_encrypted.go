@@ -0,0 +1,59 @@
+// Copyright 2011 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package packet
+
+import (
+	"crypto/openpgp/error"
+	"io"
+	"os"
+	"strconv"
+)
+
+const publicKeyEncryptedVersion = 3
+
+// PublicKeyEncrypted represents a public-key encrypted session key. See RFC
+// 4880, section 5.1. Only parsing is implemented: decrypting the session
+// key requires RSA/ElGamal decryption, which this package does not yet
+// provide.
+type PublicKeyEncrypted struct {
+	KeyId      uint64
+	PubKeyAlgo PublicKeyAlgorithm
+
+	encryptedMPI1, encryptedMPI2 []byte
+}
+
+func (pke *PublicKeyEncrypted) parse(r io.Reader) (err os.Error) {
+	var buf [10]byte
+	if _, err = readFull(r, buf[:]); err != nil {
+		return
+	}
+	if buf[0] != publicKeyEncryptedVersion {
+		return error.UnsupportedError("public-key encrypted session key packet version " + strconv.Itoa(int(buf[0])))
+	}
+
+	pke.KeyId = uint64(buf[1])<<56 | uint64(buf[2])<<48 | uint64(buf[3])<<40 | uint64(buf[4])<<32 |
+		uint64(buf[5])<<24 | uint64(buf[6])<<16 | uint64(buf[7])<<8 | uint64(buf[8])
+	pke.PubKeyAlgo = PublicKeyAlgorithm(buf[9])
+
+	switch pke.PubKeyAlgo {
+	case PubKeyAlgoRSA, PubKeyAlgoRSAEncryptOnly:
+		pke.encryptedMPI1, err = readMPI(r)
+	case PubKeyAlgoElGamal:
+		if pke.encryptedMPI1, err = readMPI(r); err != nil {
+			return
+		}
+		pke.encryptedMPI2, err = readMPI(r)
+	default:
+		err = error.UnsupportedError("public key algorithm for encrypted session key: " + strconv.Itoa(int(pke.PubKeyAlgo)))
+	}
+	return
+}
+
+// Decrypt returns the session key encrypted in pke, given the private key
+// to which it was encrypted. Decryption of public-key encrypted session
+// keys is not yet supported.
+func (pke *PublicKeyEncrypted) Decrypt(priv *PrivateKey) (CipherFunction, []byte, os.Error) {
+	return 0, nil, error.UnsupportedError("decrypting public-key encrypted session keys is not yet supported")
+}
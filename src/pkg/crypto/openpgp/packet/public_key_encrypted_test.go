@@ -0,0 +1,33 @@
+// Copyright 2011 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package packet
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPublicKeyEncryptedParse(t *testing.T) {
+	data := []byte{
+		3,                      // version
+		1, 2, 3, 4, 5, 6, 7, 8, // key id
+		byte(PubKeyAlgoRSA),    // public key algorithm
+		0, 3, 0xe0,             // a 3-bit MPI, stored as a single byte
+	}
+
+	pke := new(PublicKeyEncrypted)
+	if err := pke.parse(bytes.NewBuffer(data)); err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+	if pke.KeyId != 0x0102030405060708 {
+		t.Fatalf("KeyId = %x, want 0x0102030405060708", pke.KeyId)
+	}
+	if pke.PubKeyAlgo != PubKeyAlgoRSA {
+		t.Fatalf("PubKeyAlgo = %d, want %d", pke.PubKeyAlgo, PubKeyAlgoRSA)
+	}
+	if _, _, err := pke.Decrypt(nil); err == nil {
+		t.Fatalf("expected Decrypt to report that it is unsupported")
+	}
+}
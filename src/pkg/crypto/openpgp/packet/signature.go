@@ -0,0 +1,457 @@
+// Copyright 2011 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package packet
+
+import (
+	"big"
+	"bytes"
+	"crypto/dsa"
+	"crypto/openpgp/error"
+	"crypto/openpgp/s2k"
+	"crypto/rand"
+	"crypto/rsa"
+	"hash"
+	"io"
+	"os"
+	"strconv"
+)
+
+// SignatureType represents the different semantic meanings of an OpenPGP
+// signature. See RFC 4880, section 5.2.1.
+type SignatureType uint8
+
+const (
+	SigTypeBinary        SignatureType = 0
+	SigTypeText          SignatureType = 1
+	SigTypeGenericCert   SignatureType = 0x10
+	SigTypePersonaCert   SignatureType = 0x11
+	SigTypeCasualCert    SignatureType = 0x12
+	SigTypePositiveCert  SignatureType = 0x13
+	SigTypeSubkeyBinding SignatureType = 0x18
+)
+
+const signatureVersion = 4
+
+// Signature represents a signature. See RFC 4880, section 5.2.
+type Signature struct {
+	SigType    SignatureType
+	PubKeyAlgo PublicKeyAlgorithm
+	Hash       func() hash.Hash
+	HashFunc   uint8 // the RFC 4880, section 9.4 hash algorithm id
+
+	// HashSuffix is the hashed data (the fixed-length header, the hashed
+	// subpacket area) followed by the six-byte trailer that is appended
+	// before hashing, per RFC 4880, section 5.2.4. It is retained so
+	// that Verify or Serialize can reuse it without recomputing the
+	// subpacket encoding.
+	HashSuffix []byte
+	// HashTag contains the first two bytes of the hash, for fast
+	// rejection of bad signed data.
+	HashTag [2]byte
+
+	CreationTime uint32 // Unix epoch seconds
+
+	IssuerKeyId        *uint64
+	SigLifetimeSecs    *uint32
+	KeyLifetimeSecs    *uint32
+	PreferredSymmetric []uint8
+
+	FlagsValid                bool
+	FlagCertify               bool
+	FlagSign                  bool
+	FlagEncryptCommunications bool
+	FlagEncryptStorage        bool
+
+	// RSA signature value
+	RSASignature *big.Int
+	// DSA signature values
+	DSASigR, DSASigS *big.Int
+
+	outSubpackets []outputSubpacket
+}
+
+func (sig *Signature) parse(r io.Reader) (err os.Error) {
+	var buf [6]byte
+	if _, err = readFull(r, buf[:1]); err != nil {
+		return
+	}
+	if buf[0] != signatureVersion {
+		return error.UnsupportedError("signature packet version " + strconv.Itoa(int(buf[0])))
+	}
+
+	if _, err = readFull(r, buf[:5]); err != nil {
+		return
+	}
+	sig.SigType = SignatureType(buf[0])
+	sig.PubKeyAlgo = PublicKeyAlgorithm(buf[1])
+	switch sig.PubKeyAlgo {
+	case PubKeyAlgoRSA, PubKeyAlgoRSASignOnly, PubKeyAlgoDSA:
+	default:
+		return error.UnsupportedError("public key algorithm " + strconv.Itoa(int(sig.PubKeyAlgo)))
+	}
+
+	var ok bool
+	sig.Hash, ok = s2k.HashIdToHash(buf[2])
+	if !ok {
+		return error.UnsupportedError("hash function " + strconv.Itoa(int(buf[2])))
+	}
+	sig.HashFunc = buf[2]
+
+	hashedSubpacketsLength := int(buf[3])<<8 | int(buf[4])
+	hashedSubpackets := make([]byte, hashedSubpacketsLength)
+	if _, err = readFull(r, hashedSubpackets); err != nil {
+		return
+	}
+
+	// We need to hash the signed data and the packet's own header when
+	// verifying, so the bytes that will be hashed are retained here. See
+	// RFC 4880, section 5.2.4.
+	sigHeader := [6]byte{signatureVersion, buf[0], buf[1], buf[2], buf[3], buf[4]}
+	sigLength := len(sigHeader) + hashedSubpacketsLength
+	sig.HashSuffix = make([]byte, sigLength+6)
+	copy(sig.HashSuffix, sigHeader[:])
+	copy(sig.HashSuffix[6:], hashedSubpackets)
+	trailer := sig.HashSuffix[sigLength:]
+	trailer[0] = signatureVersion
+	trailer[1] = 0xff
+	trailer[2] = byte(sigLength >> 24)
+	trailer[3] = byte(sigLength >> 16)
+	trailer[4] = byte(sigLength >> 8)
+	trailer[5] = byte(sigLength)
+
+	if err = sig.parseSubpackets(hashedSubpackets, true); err != nil {
+		return
+	}
+
+	if _, err = readFull(r, buf[:2]); err != nil {
+		return
+	}
+	unhashedSubpacketsLength := int(buf[0])<<8 | int(buf[1])
+	unhashedSubpackets := make([]byte, unhashedSubpacketsLength)
+	if _, err = readFull(r, unhashedSubpackets); err != nil {
+		return
+	}
+	if err = sig.parseSubpackets(unhashedSubpackets, false); err != nil {
+		return
+	}
+
+	if _, err = readFull(r, sig.HashTag[:]); err != nil {
+		return
+	}
+
+	switch sig.PubKeyAlgo {
+	case PubKeyAlgoRSA, PubKeyAlgoRSASignOnly:
+		var rsaBytes []byte
+		if rsaBytes, err = readMPI(r); err != nil {
+			return
+		}
+		sig.RSASignature = new(big.Int).SetBytes(rsaBytes)
+	case PubKeyAlgoDSA:
+		var rBytes, sBytes []byte
+		if rBytes, err = readMPI(r); err != nil {
+			return
+		}
+		if sBytes, err = readMPI(r); err != nil {
+			return
+		}
+		sig.DSASigR = new(big.Int).SetBytes(rBytes)
+		sig.DSASigS = new(big.Int).SetBytes(sBytes)
+	}
+	return
+}
+
+type signatureSubpacketType uint8
+
+const (
+	creationTimeSubpacket       signatureSubpacketType = 2
+	sigExpirationSubpacket      signatureSubpacketType = 3
+	keyExpirationSubpacket      signatureSubpacketType = 9
+	prefSymmetricAlgosSubpacket signatureSubpacketType = 11
+	issuerSubpacket             signatureSubpacketType = 16
+	keyFlagsSubpacket           signatureSubpacketType = 27
+)
+
+// parseSubpackets parses the given signature subpacket area, which is
+// either the hashed or unhashed area of a v4 signature. See RFC 4880,
+// section 5.2.3.1.
+func (sig *Signature) parseSubpackets(subpackets []byte, isHashed bool) (err os.Error) {
+	for len(subpackets) > 0 {
+		length := int(subpackets[0])
+		packet := subpackets[1:]
+		switch {
+		case length == 255:
+			if len(subpackets) < 5 {
+				return error.StructuralError("invalid subpacket length")
+			}
+			length = int(subpackets[1])<<24 | int(subpackets[2])<<16 | int(subpackets[3])<<8 | int(subpackets[4])
+			packet = subpackets[5:]
+		case length > 191:
+			if len(subpackets) < 2 {
+				return error.StructuralError("invalid subpacket length")
+			}
+			length = (length-192)<<8 + int(subpackets[1]) + 192
+			packet = subpackets[2:]
+		}
+		if length == 0 || len(packet) < length {
+			return error.StructuralError("subpacket longer than remaining data")
+		}
+		packet, subpackets = packet[:length], packet[length:]
+		subpacketType := signatureSubpacketType(packet[0] & 0x7f)
+		isCritical := packet[0]&0x80 == 0x80
+		packet = packet[1:]
+
+		switch subpacketType {
+		case creationTimeSubpacket:
+			if !isHashed {
+				return error.StructuralError("signature creation time in unhashed area")
+			}
+			if len(packet) != 4 {
+				return error.StructuralError("signature creation time not four bytes")
+			}
+			sig.CreationTime = uint32(packet[0])<<24 | uint32(packet[1])<<16 | uint32(packet[2])<<8 | uint32(packet[3])
+		case sigExpirationSubpacket:
+			if len(packet) != 4 {
+				return error.StructuralError("signature expiration subpacket not four bytes")
+			}
+			v := uint32(packet[0])<<24 | uint32(packet[1])<<16 | uint32(packet[2])<<8 | uint32(packet[3])
+			sig.SigLifetimeSecs = &v
+		case keyExpirationSubpacket:
+			if len(packet) != 4 {
+				return error.StructuralError("key expiration subpacket not four bytes")
+			}
+			v := uint32(packet[0])<<24 | uint32(packet[1])<<16 | uint32(packet[2])<<8 | uint32(packet[3])
+			sig.KeyLifetimeSecs = &v
+		case prefSymmetricAlgosSubpacket:
+			sig.PreferredSymmetric = packet
+		case issuerSubpacket:
+			if len(packet) != 8 {
+				return error.StructuralError("issuer subpacket not eight bytes")
+			}
+			v := uint64(packet[0])<<56 | uint64(packet[1])<<48 | uint64(packet[2])<<40 | uint64(packet[3])<<32 |
+				uint64(packet[4])<<24 | uint64(packet[5])<<16 | uint64(packet[6])<<8 | uint64(packet[7])
+			sig.IssuerKeyId = &v
+		case keyFlagsSubpacket:
+			if len(packet) == 0 {
+				return error.StructuralError("empty key flags subpacket")
+			}
+			sig.FlagsValid = true
+			sig.FlagCertify = packet[0]&1 != 0
+			sig.FlagSign = packet[0]&2 != 0
+			sig.FlagEncryptCommunications = packet[0]&4 != 0
+			sig.FlagEncryptStorage = packet[0]&8 != 0
+		default:
+			if isCritical {
+				return error.UnsupportedError("unknown critical signature subpacket type " + strconv.Itoa(int(subpacketType)))
+			}
+		}
+	}
+	return
+}
+
+// Verify checks that hash, which must already have been written with
+// exactly the data that was signed, together with the signature's own
+// hashed subpacket area, matches sig and was produced by pub.
+func (sig *Signature) Verify(h hash.Hash, pub *PublicKey) (err os.Error) {
+	h.Write(sig.HashSuffix)
+	digest := h.Sum()
+	if digest[0] != sig.HashTag[0] || digest[1] != sig.HashTag[1] {
+		return error.SignatureError("hash tag doesn't match")
+	}
+
+	switch sig.PubKeyAlgo {
+	case PubKeyAlgoRSA, PubKeyAlgoRSASignOnly:
+		cryptoHash, ok := s2k.HashIdToCryptoHash(sig.HashFunc)
+		if !ok {
+			return error.UnsupportedError("hash function " + strconv.Itoa(int(sig.HashFunc)))
+		}
+		rsaPublicKey := &rsa.PublicKey{N: pub.n, E: int(pub.e.Int64())}
+		if err = rsa.VerifyPKCS1v15(rsaPublicKey, cryptoHash, digest, sig.RSASignature.Bytes()); err != nil {
+			return error.SignatureError("RSA verification failure")
+		}
+		return nil
+	case PubKeyAlgoDSA:
+		dsaPublicKey := &dsa.PublicKey{
+			Parameters: dsa.Parameters{P: pub.p, Q: pub.q, G: pub.g},
+			Y:          pub.y,
+		}
+		if !dsa.Verify(dsaPublicKey, digest, sig.DSASigR, sig.DSASigS) {
+			return error.SignatureError("DSA verification failure")
+		}
+		return nil
+	}
+	return error.UnsupportedError("public key algorithm: " + strconv.Itoa(int(sig.PubKeyAlgo)))
+}
+
+// Sign signs a message with a private key, populating the signature value
+// fields of sig. hash must already have been written with exactly the data
+// to be signed, and sig.PubKeyAlgo, sig.HashFunc and sig.CreationTime must
+// already be set.
+func (sig *Signature) Sign(h hash.Hash, priv *PrivateKey) (err os.Error) {
+	digest, err := sig.signPrepareHash(h)
+	if err != nil {
+		return
+	}
+
+	switch priv.PubKeyAlgo {
+	case PubKeyAlgoRSA, PubKeyAlgoRSASignOnly:
+		rsaPriv, ok := priv.PrivateKey.(*rsa.PrivateKey)
+		if !ok {
+			return error.InvalidArgumentError("signing key is not an RSA key")
+		}
+		cryptoHash, ok := s2k.HashIdToCryptoHash(sig.HashFunc)
+		if !ok {
+			return error.UnsupportedError("hash function " + strconv.Itoa(int(sig.HashFunc)))
+		}
+		var sigBytes []byte
+		if sigBytes, err = rsa.SignPKCS1v15(rand.Reader, rsaPriv, cryptoHash, digest); err != nil {
+			return error.SignatureError("RSA signing failure: " + err.String())
+		}
+		sig.RSASignature = new(big.Int).SetBytes(sigBytes)
+	case PubKeyAlgoDSA:
+		dsaPriv, ok := priv.PrivateKey.(*dsa.PrivateKey)
+		if !ok {
+			return error.InvalidArgumentError("signing key is not a DSA key")
+		}
+		sig.DSASigR, sig.DSASigS, err = dsa.Sign(rand.Reader, dsaPriv, digest)
+	default:
+		err = error.UnsupportedError("public key algorithm: " + strconv.Itoa(int(priv.PubKeyAlgo)))
+	}
+	return
+}
+
+// signPrepareHash builds the hashed subpacket area and HashSuffix/HashTag
+// from sig's fields, writes HashSuffix into h and returns the digest to be
+// signed.
+func (sig *Signature) signPrepareHash(h hash.Hash) (digest []byte, err os.Error) {
+	sig.outSubpackets = sig.buildSubpackets()
+	hashedSubpackets := sig.serializeSubpackets(true)
+
+	var sigHeader [6]byte
+	sigHeader[0] = signatureVersion
+	sigHeader[1] = byte(sig.SigType)
+	sigHeader[2] = byte(sig.PubKeyAlgo)
+	sigHeader[3] = sig.HashFunc
+	sigHeader[4] = byte(len(hashedSubpackets) >> 8)
+	sigHeader[5] = byte(len(hashedSubpackets))
+
+	sigLength := len(sigHeader) + len(hashedSubpackets)
+	sig.HashSuffix = make([]byte, sigLength+6)
+	copy(sig.HashSuffix, sigHeader[:])
+	copy(sig.HashSuffix[6:], hashedSubpackets)
+	trailer := sig.HashSuffix[sigLength:]
+	trailer[0] = signatureVersion
+	trailer[1] = 0xff
+	trailer[2] = byte(sigLength >> 24)
+	trailer[3] = byte(sigLength >> 16)
+	trailer[4] = byte(sigLength >> 8)
+	trailer[5] = byte(sigLength)
+
+	h.Write(sig.HashSuffix)
+	digest = h.Sum()
+	sig.HashTag[0] = digest[0]
+	sig.HashTag[1] = digest[1]
+	return
+}
+
+// outputSubpacket is a subpacket to be serialized, tagged with which
+// subpacket area (hashed or unhashed) it belongs in.
+type outputSubpacket struct {
+	hashed        bool
+	subpacketType signatureSubpacketType
+	isCritical    bool
+	contents      []byte
+}
+
+// buildSubpackets constructs the set of subpackets to serialize from sig's
+// fields: the creation time is always hashed, and the issuer key id, if
+// set, is left unhashed, matching common practice for v4 signatures.
+func (sig *Signature) buildSubpackets() (subpackets []outputSubpacket) {
+	var creationTime [4]byte
+	creationTime[0] = byte(sig.CreationTime >> 24)
+	creationTime[1] = byte(sig.CreationTime >> 16)
+	creationTime[2] = byte(sig.CreationTime >> 8)
+	creationTime[3] = byte(sig.CreationTime)
+	subpackets = append(subpackets, outputSubpacket{true, creationTimeSubpacket, false, creationTime[:]})
+
+	if sig.IssuerKeyId != nil {
+		var keyId [8]byte
+		id := *sig.IssuerKeyId
+		for i := 0; i < 8; i++ {
+			keyId[i] = byte(id >> uint(56-8*i))
+		}
+		subpackets = append(subpackets, outputSubpacket{false, issuerSubpacket, false, keyId[:]})
+	}
+
+	return
+}
+
+// serializeSubpackets returns the serialized form of the hashed (or
+// unhashed) subpackets in sig.outSubpackets. See RFC 4880, section 5.2.3.1.
+func (sig *Signature) serializeSubpackets(hashed bool) []byte {
+	buf := bytes.NewBuffer(nil)
+	for _, subpacket := range sig.outSubpackets {
+		if subpacket.hashed != hashed {
+			continue
+		}
+		buf.WriteByte(byte(len(subpacket.contents) + 1))
+		tag := byte(subpacket.subpacketType)
+		if subpacket.isCritical {
+			tag |= 0x80
+		}
+		buf.WriteByte(tag)
+		buf.Write(subpacket.contents)
+	}
+	return buf.Bytes()
+}
+
+// Serialize marshals sig to w. Sign must have been called first.
+func (sig *Signature) Serialize(w io.Writer) (err os.Error) {
+	if sig.RSASignature == nil && (sig.DSASigR == nil || sig.DSASigS == nil) {
+		return error.InvalidArgumentError("Signature: can't serialize a Signature that has not been signed")
+	}
+
+	sigLength := 0
+	switch sig.PubKeyAlgo {
+	case PubKeyAlgoRSA, PubKeyAlgoRSASignOnly:
+		sigLength = mpiLength(sig.RSASignature)
+	case PubKeyAlgoDSA:
+		sigLength = mpiLength(sig.DSASigR) + mpiLength(sig.DSASigS)
+	default:
+		panic("impossible")
+	}
+
+	unhashedSubpackets := sig.serializeSubpackets(false)
+	length := len(sig.HashSuffix) - 6 /* trailer, not on the wire */ +
+		2 /* unhashed subpacket length */ + len(unhashedSubpackets) +
+		2 /* hash tag */ + sigLength
+
+	if err = serializeHeader(w, packetTypeSignature, length); err != nil {
+		return
+	}
+	if _, err = w.Write(sig.HashSuffix[:len(sig.HashSuffix)-6]); err != nil {
+		return
+	}
+	unhashedSubpacketsLength := [2]byte{byte(len(unhashedSubpackets) >> 8), byte(len(unhashedSubpackets))}
+	if _, err = w.Write(unhashedSubpacketsLength[:]); err != nil {
+		return
+	}
+	if _, err = w.Write(unhashedSubpackets); err != nil {
+		return
+	}
+	if _, err = w.Write(sig.HashTag[:]); err != nil {
+		return
+	}
+
+	switch sig.PubKeyAlgo {
+	case PubKeyAlgoRSA, PubKeyAlgoRSASignOnly:
+		err = writeMPI(w, sig.RSASignature)
+	case PubKeyAlgoDSA:
+		if err = writeMPI(w, sig.DSASigR); err != nil {
+			return
+		}
+		err = writeMPI(w, sig.DSASigS)
+	}
+	return
+}
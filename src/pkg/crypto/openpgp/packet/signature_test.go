@@ -0,0 +1,94 @@
+// Copyright 2011 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package packet
+
+import (
+	"big"
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"testing"
+)
+
+// testRSAKeyPair returns a packet-level public/private RSA key pair,
+// suitable for signing in tests.
+func testRSAKeyPair(t *testing.T) (*PublicKey, *PrivateKey) {
+	rsaPriv, err := rsa.GenerateKey(rand.Reader, 512)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey: %s", err)
+	}
+
+	pub := &PublicKey{
+		PubKeyAlgo: PubKeyAlgoRSA,
+		n:          rsaPriv.N,
+		e:          big.NewInt(int64(rsaPriv.E)),
+	}
+	priv := &PrivateKey{PublicKey: *pub, PrivateKey: rsaPriv}
+	return pub, priv
+}
+
+func TestSignatureRSARoundTrip(t *testing.T) {
+	pub, priv := testRSAKeyPair(t)
+	message := []byte("the quick brown fox jumps over the lazy dog")
+
+	sig := &Signature{
+		SigType:      SigTypeBinary,
+		PubKeyAlgo:   PubKeyAlgoRSA,
+		Hash:         sha1.New,
+		HashFunc:     2, // SHA-1, see s2k.HashIdToHash
+		CreationTime: 1234567890,
+	}
+
+	h := sha1.New()
+	h.Write(message)
+	if err := sig.Sign(h, priv); err != nil {
+		t.Fatalf("Sign: %s", err)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err := sig.Serialize(buf); err != nil {
+		t.Fatalf("Serialize: %s", err)
+	}
+
+	p, err := Read(buf)
+	if err != nil {
+		t.Fatalf("Read: %s", err)
+	}
+	sig2, ok := p.(*Signature)
+	if !ok {
+		t.Fatalf("Read returned %T, not *Signature", p)
+	}
+
+	h2 := sha1.New()
+	h2.Write(message)
+	if err := sig2.Verify(h2, pub); err != nil {
+		t.Fatalf("Verify: %s", err)
+	}
+}
+
+func TestSignatureVerifyTamperedMessageFails(t *testing.T) {
+	pub, priv := testRSAKeyPair(t)
+
+	sig := &Signature{
+		SigType:      SigTypeBinary,
+		PubKeyAlgo:   PubKeyAlgoRSA,
+		Hash:         sha1.New,
+		HashFunc:     2,
+		CreationTime: 1234567890,
+	}
+
+	h := sha1.New()
+	h.Write([]byte("the quick brown fox jumps over the lazy dog"))
+	if err := sig.Sign(h, priv); err != nil {
+		t.Fatalf("Sign: %s", err)
+	}
+
+	h2 := sha1.New()
+	h2.Write([]byte("the quick brown fox jumps over the lazy dog, mostly"))
+	if err := sig.Verify(h2, pub); err == nil {
+		t.Fatalf("Verify succeeded on a tampered message, want an error")
+	}
+}
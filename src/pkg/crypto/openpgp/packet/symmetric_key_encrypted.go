@@ -0,0 +1,129 @@
+// Copyright 2011 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package packet
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/openpgp/error"
+	"crypto/openpgp/s2k"
+	"crypto/rand"
+	"io"
+	"os"
+	"strconv"
+)
+
+// SymmetricKeyEncrypted represents a passphrase protected session key. See
+// RFC 4880, section 5.3.
+type SymmetricKeyEncrypted struct {
+	CipherFunc   CipherFunction
+	s2k          func(out, in []byte)
+	encryptedKey []byte
+}
+
+const symmetricKeyEncryptedVersion = 4
+
+func (ske *SymmetricKeyEncrypted) parse(r io.Reader) os.Error {
+	var buf [1]byte
+	if _, err := readFull(r, buf[:]); err != nil {
+		return err
+	}
+	if buf[0] != symmetricKeyEncryptedVersion {
+		return error.UnsupportedError("SymmetricKeyEncrypted version")
+	}
+	if _, err := readFull(r, buf[:]); err != nil {
+		return err
+	}
+	ske.CipherFunc = CipherFunction(buf[0])
+
+	if ske.CipherFunc.keySize() == 0 {
+		return error.UnsupportedError("unknown cipher: " + strconv.Itoa(int(ske.CipherFunc)))
+	}
+
+	var err os.Error
+	ske.s2k, err = s2k.Parse(r)
+	if err != nil {
+		return err
+	}
+
+	// The encrypted session key is optional: it's present only if the
+	// S2K-derived key isn't used as the session key directly. We just
+	// have to try and read to find out, limited to a sane maximum so
+	// that we don't attempt to read the rest of the message.
+	encryptedKey := make([]byte, maxSessionKeySizeInBytes)
+	n, err := readFull(r, encryptedKey)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	if n != 0 {
+		ske.encryptedKey = encryptedKey[:n]
+	}
+
+	return nil
+}
+
+// maxSessionKeySizeInBytes is the maximum size, in bytes, of a session key
+// for any cipher that we know about.
+const maxSessionKeySizeInBytes = 64
+
+// Decrypt returns the session key and the cipher to use when decrypting a
+// subsequent SymmetricallyEncrypted packet, given the passphrase that the
+// message was encrypted with.
+func (ske *SymmetricKeyEncrypted) Decrypt(passphrase []byte) (CipherFunction, []byte, os.Error) {
+	key := make([]byte, ske.CipherFunc.keySize())
+	ske.s2k(key, passphrase)
+
+	if len(ske.encryptedKey) == 0 {
+		return ske.CipherFunc, key, nil
+	}
+
+	// The session key is encrypted with the S2K-derived key, using CFB
+	// mode with an IV of all zeros.
+	iv := make([]byte, ske.CipherFunc.blockSize())
+	c := cipher.NewCFBDecrypter(ske.CipherFunc.new(key), iv)
+	plaintextKey := make([]byte, len(ske.encryptedKey))
+	c.XORKeyStream(plaintextKey, ske.encryptedKey)
+
+	cipherFunc := CipherFunction(plaintextKey[0])
+	if cipherFunc.blockSize() == 0 {
+		return ske.CipherFunc, nil, error.UnsupportedError("unknown cipher: " + strconv.Itoa(int(cipherFunc)))
+	}
+
+	plaintextKey = plaintextKey[1:]
+	if len(plaintextKey) != cipherFunc.keySize() {
+		return cipherFunc, nil, error.StructuralError("length of decrypted key not equal to cipher keysize")
+	}
+
+	return cipherFunc, plaintextKey, nil
+}
+
+// SerializeSymmetricKeyEncrypted serializes a symmetric key encryption
+// packet to w, deriving the session key from passphrase via an Iterated and
+// Salted S2K function with a freshly generated salt. config may be nil, in
+// which case sensible defaults are used; see s2k.Config. The derived key,
+// which is also the session key to use with SerializeSymmetricallyEncrypted,
+// is returned.
+func SerializeSymmetricKeyEncrypted(w io.Writer, passphrase []byte, cipherFunc CipherFunction, config *s2k.Config) (key []byte, err os.Error) {
+	keySize := cipherFunc.keySize()
+	if keySize == 0 {
+		return nil, error.UnsupportedError("unknown cipher: " + strconv.Itoa(int(cipherFunc)))
+	}
+
+	key = make([]byte, keySize)
+	s2kBuf := bytes.NewBuffer(nil)
+	if err = s2k.Serialize(s2kBuf, key, rand.Reader, passphrase, config); err != nil {
+		return nil, err
+	}
+
+	packetLength := 2 /* version, cipher */ + s2kBuf.Len()
+	if err = serializeHeader(w, packetTypeSymmetricKeyEncrypted, packetLength); err != nil {
+		return
+	}
+	if _, err = w.Write([]byte{symmetricKeyEncryptedVersion, byte(cipherFunc)}); err != nil {
+		return
+	}
+	_, err = w.Write(s2kBuf.Bytes())
+	return
+}
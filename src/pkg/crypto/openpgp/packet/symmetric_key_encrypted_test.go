@@ -0,0 +1,89 @@
+// Copyright 2011 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package packet
+
+import (
+	"bytes"
+	"crypto/openpgp/s2k"
+	"crypto/sha256"
+	"testing"
+)
+
+func TestSymmetricKeyEncryptedRoundTrip(t *testing.T) {
+	passphrase := []byte("correct horse battery staple")
+
+	buf := bytes.NewBuffer(nil)
+	key, err := SerializeSymmetricKeyEncrypted(buf, passphrase, CipherAES128, nil)
+	if err != nil {
+		t.Fatalf("SerializeSymmetricKeyEncrypted: %s", err)
+	}
+
+	p, err := Read(buf)
+	if err != nil {
+		t.Fatalf("Read: %s", err)
+	}
+	ske, ok := p.(*SymmetricKeyEncrypted)
+	if !ok {
+		t.Fatalf("Read returned %T, not *SymmetricKeyEncrypted", p)
+	}
+
+	gotCipher, gotKey, err := ske.Decrypt(passphrase)
+	if err != nil {
+		t.Fatalf("Decrypt: %s", err)
+	}
+	if gotCipher != CipherAES128 {
+		t.Fatalf("cipher is %d, want %d", gotCipher, CipherAES128)
+	}
+	if !bytes.Equal(gotKey, key) {
+		t.Fatalf("decrypted key is %x, want %x", gotKey, key)
+	}
+}
+
+func TestSymmetricKeyEncryptedCustomConfig(t *testing.T) {
+	passphrase := []byte("correct horse battery staple")
+	config := &s2k.Config{Hash: sha256.New, S2KCount: 1024}
+
+	buf := bytes.NewBuffer(nil)
+	key, err := SerializeSymmetricKeyEncrypted(buf, passphrase, CipherAES256, config)
+	if err != nil {
+		t.Fatalf("SerializeSymmetricKeyEncrypted: %s", err)
+	}
+
+	p, err := Read(buf)
+	if err != nil {
+		t.Fatalf("Read: %s", err)
+	}
+	ske := p.(*SymmetricKeyEncrypted)
+
+	_, gotKey, err := ske.Decrypt(passphrase)
+	if err != nil {
+		t.Fatalf("Decrypt: %s", err)
+	}
+	if !bytes.Equal(gotKey, key) {
+		t.Fatalf("decrypted key is %x, want %x", gotKey, key)
+	}
+}
+
+func TestSymmetricKeyEncryptedWrongPassphrase(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	key, err := SerializeSymmetricKeyEncrypted(buf, []byte("right passphrase"), CipherAES128, nil)
+	if err != nil {
+		t.Fatalf("SerializeSymmetricKeyEncrypted: %s", err)
+	}
+
+	p, err := Read(buf)
+	if err != nil {
+		t.Fatalf("Read: %s", err)
+	}
+	ske := p.(*SymmetricKeyEncrypted)
+
+	_, gotKey, err := ske.Decrypt([]byte("wrong passphrase"))
+	if err != nil {
+		t.Fatalf("Decrypt: %s", err)
+	}
+	if bytes.Equal(gotKey, key) {
+		t.Fatalf("decrypting with the wrong passphrase produced the right key")
+	}
+}
@@ -7,6 +7,7 @@ package packet
 import (
 	"crypto/cipher"
 	"crypto/openpgp/error"
+	"crypto/rand"
 	"crypto/sha1"
 	"crypto/subtle"
 	"hash"
@@ -101,6 +102,10 @@ func (ser seReader) Close() os.Error {
 
 const mdcTrailerSize = 1 /* tag byte */ + 1 /* length byte */ + sha1.Size
 
+// mdcPacketTagByte is the new-format packet tag byte for a type 19 (MDC)
+// packet.
+const mdcPacketTagByte = byte(0x80) | 0x40 | 19
+
 // An seMDCReader wraps an io.Reader, maintains a running hash and keeps hold
 // of the most recent 22 bytes (mdcTrailerSize). Upon EOF, those bytes form an
 // MDC packet containing a hash of the previous contents which is checked
@@ -191,16 +196,96 @@ func (ser *seMDCReader) Close() os.Error {
 		}
 	}
 
-	// This is a new-format packet tag byte for a type 19 (MDC) packet.
-	const mdcPacketTagByte = byte(0x80) | 0x40 | 19
 	if ser.trailer[0] != mdcPacketTagByte || ser.trailer[1] != sha1.Size {
 		return error.SignatureError("MDC packet not found")
 	}
 	ser.h.Write(ser.trailer[:2])
 
 	final := ser.h.Sum()
-	if subtle.ConstantTimeCompare(final, ser.trailer[2:]) == 1 {
+	if subtle.ConstantTimeCompare(final, ser.trailer[2:]) != 1 {
 		return error.SignatureError("hash mismatch")
 	}
 	return nil
 }
+
+// SerializeSymmetricallyEncrypted serializes a symmetrically encrypted packet
+// to w and returns a WriteCloser to which the to-be-encrypted packets can be
+// written. The resulting packet is always MDC-protected (type 18, see RFC
+// 4880, section 5.13); the returned WriteCloser must be closed to append the
+// MDC trailer once all data has been written.
+func SerializeSymmetricallyEncrypted(w io.Writer, c CipherFunction, key []byte) (contents io.WriteCloser, err os.Error) {
+	keySize := c.keySize()
+	if keySize == 0 {
+		return nil, error.UnsupportedError("unknown cipher: " + strconv.Itoa(int(c)))
+	}
+	if len(key) != keySize {
+		return nil, error.InvalidArgumentError("SymmetricallyEncrypted: incorrect key length")
+	}
+
+	out, err := serializeStreamHeader(w, packetTypeSymmetricallyEncryptedMDC)
+	if err != nil {
+		return
+	}
+
+	_, err = out.Write([]byte{1}) // MDC packet version
+	if err != nil {
+		return
+	}
+
+	block := c.new(key)
+	blockSize := block.BlockSize()
+	iv := make([]byte, blockSize)
+	_, err = readFull(rand.Reader, iv)
+	if err != nil {
+		return
+	}
+	s, prefix := cipher.NewOCFBEncrypter(block, iv, cipher.OCFBNoResync)
+	if s == nil {
+		return nil, error.UnsupportedError("unsupported cipher: " + strconv.Itoa(int(c)))
+	}
+	_, err = out.Write(prefix)
+	if err != nil {
+		return
+	}
+
+	// The hash is keyed with the plaintext IV, not the encrypted prefix
+	// that was just written to the wire; see RFC 4880, section 5.13.
+	h := sha1.New()
+	h.Write(iv)
+	h.Write(iv[blockSize-2:])
+
+	contents = &seMDCWriter{w: cipher.StreamWriter{S: s, W: out}, h: h, closer: out}
+	return
+}
+
+// seMDCWriter writes the contents of an MDC-protected packet, feeding a
+// running hash that is used to construct the MDC trailer on Close. See RFC
+// 4880, section 5.13.
+type seMDCWriter struct {
+	w      io.Writer
+	h      hash.Hash
+	closer io.Closer
+}
+
+func (w *seMDCWriter) Write(buf []byte) (n int, err os.Error) {
+	w.h.Write(buf)
+	return w.w.Write(buf)
+}
+
+func (w *seMDCWriter) Close() (err os.Error) {
+	var buf [2]byte
+	buf[0] = mdcPacketTagByte
+	buf[1] = sha1.Size
+	w.h.Write(buf[:])
+
+	digest := w.h.Sum()
+	_, err = w.w.Write(buf[:])
+	if err != nil {
+		return
+	}
+	_, err = w.w.Write(digest)
+	if err != nil {
+		return
+	}
+	return w.closer.Close()
+}
@@ -0,0 +1,230 @@
+// Copyright 2011 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package openpgp implements high level operations on OpenPGP messages, as
+// specified in RFC 4880.
+package openpgp
+
+import (
+	"crypto/openpgp/error"
+	"crypto/openpgp/packet"
+	"hash"
+	"io"
+	"os"
+)
+
+// PromptFunction is used as a callback by functions that may need to
+// retrieve a passphrase from a user or otherwise prompt for information.
+// keys is the set of encryption-capable private keys that a message was
+// encrypted to, or nil if it was encrypted with a passphrase. symmetric is
+// true if the function is being asked for a passphrase to derive a
+// symmetric session key.
+type PromptFunction func(keys []Key, symmetric bool) (passphrase []byte, err os.Error)
+
+// Key represents a public key, and possibly the private portion of that key,
+// that belongs to an identity.
+type Key struct {
+	PublicKey  *packet.PublicKey
+	PrivateKey *packet.PrivateKey
+}
+
+// KeyRing represents a set of keys.
+type KeyRing interface {
+	// KeysById returns the set of keys that have the given key id.
+	KeysById(id uint64) []Key
+}
+
+// MessageDetails contains the result of parsing an OpenPGP encrypted and/or
+// signed message.
+type MessageDetails struct {
+	IsEncrypted              bool     // true if the message was encrypted.
+	EncryptedToKeyIds        []uint64 // the list of recipient key ids, if the message was encrypted to public keys.
+	IsSymmetricallyEncrypted bool     // true if a passphrase could have decrypted the message.
+	DecryptedWith            Key      // the private key used to decrypt the message, if any.
+
+	IsSigned      bool   // true if the message is signed.
+	SignedByKeyId uint64 // the key id of the signer, if IsSigned.
+	SignedBy      *Key   // the key of the signer, if available and IsSigned.
+
+	LiteralData *packet.LiteralData // the literal data packet, if the message contained one.
+
+	// UnverifiedBody is the plaintext contents of the message's literal
+	// data packet. It must be read and completely consumed before the
+	// message can be trusted: completely reading it to EOF checks the
+	// OpenPGP MDC of an MDC-protected (type 18) packet, returning an error
+	// from Read if it is missing or does not match, and, if IsSigned,
+	// verifies the accompanying signature, recording the outcome in
+	// SignatureError. A type 9 packet carries no MDC, and nothing is
+	// checked for it.
+	UnverifiedBody io.Reader
+
+	// SignatureError is nil if the message is not signed, or if IsSigned
+	// is true and the signature was successfully verified. Otherwise it
+	// holds the reason verification failed. It is only valid once
+	// UnverifiedBody has been read to EOF.
+	SignatureError os.Error
+
+	decrypted io.ReadCloser
+
+	// packets is the reader that subsequent OpenPGP packets (the trailing
+	// Signature packet, if any) are read from. It starts out as decrypted,
+	// but is replaced with the body of a Compressed packet's decompressor
+	// if the message's contents were compressed.
+	packets io.Reader
+}
+
+// ReadMessage parses an OpenPGP message that is symmetrically encrypted
+// (with a passphrase) and may also be signed. A message that is not
+// encrypted is not handled: ReadMessage returns an error for it. keyring is
+// used to look up the
+// signer's public key by key id, if the message is signed, and may be nil
+// if the caller has no need to verify signatures; it is not yet used for
+// public-key encrypted session keys. If the message is passphrase-encrypted,
+// prompt is called to retrieve the passphrase.
+func ReadMessage(r io.Reader, keyring KeyRing, prompt PromptFunction) (md *MessageDetails, err os.Error) {
+	md = new(MessageDetails)
+
+	var se *packet.SymmetricallyEncrypted
+	var cipherFunc packet.CipherFunction
+	var sessionKey []byte
+
+FindSessionKey:
+	for {
+		var p packet.Packet
+		if p, err = packet.Read(r); err != nil {
+			return nil, err
+		}
+
+		switch p := p.(type) {
+		case *packet.PublicKeyEncrypted:
+			md.EncryptedToKeyIds = append(md.EncryptedToKeyIds, p.KeyId)
+		case *packet.SymmetricKeyEncrypted:
+			md.IsSymmetricallyEncrypted = true
+			var passphrase []byte
+			if passphrase, err = prompt(nil, true); err != nil {
+				return nil, err
+			}
+			if cipherFunc, sessionKey, err = p.Decrypt(passphrase); err != nil {
+				return nil, err
+			}
+		case *packet.SymmetricallyEncrypted:
+			se = p
+			break FindSessionKey
+		}
+	}
+
+	if sessionKey == nil {
+		return nil, error.UnsupportedError("message is not encrypted to a passphrase; public-key encrypted session keys are not yet supported")
+	}
+
+	md.IsEncrypted = true
+	if md.decrypted, err = se.Decrypt(cipherFunc, sessionKey); err != nil {
+		return nil, err
+	}
+
+	md.packets = md.decrypted
+	var p packet.Packet
+	if p, err = packet.Read(md.packets); err != nil {
+		return nil, err
+	}
+
+	// The encrypted contents are typically wrapped in a Compressed packet,
+	// possibly several deep; descend through them transparently to reach
+	// the signature and literal data packets beneath. See RFC 4880,
+	// section 5.6.
+	for {
+		compressed, ok := p.(*packet.Compressed)
+		if !ok {
+			break
+		}
+		md.packets = compressed.Body
+		if p, err = packet.Read(md.packets); err != nil {
+			return nil, err
+		}
+	}
+
+	var ops *packet.OnePassSignature
+	if sigPacket, ok := p.(*packet.OnePassSignature); ok {
+		md.IsSigned = true
+		md.SignedByKeyId = sigPacket.KeyId
+		if keyring != nil {
+			if keys := keyring.KeysById(sigPacket.KeyId); len(keys) > 0 {
+				md.SignedBy = &keys[0]
+			}
+		}
+		ops = sigPacket
+
+		if p, err = packet.Read(md.packets); err != nil {
+			return nil, err
+		}
+	}
+
+	literalData, ok := p.(*packet.LiteralData)
+	if !ok {
+		return nil, error.UnsupportedError("unexpected packet found where literal data packet was expected")
+	}
+	md.LiteralData = literalData
+
+	if md.IsSigned {
+		h := ops.Hash()
+		md.UnverifiedBody = &signatureCheckReader{md, h, io.TeeReader(literalData.Body, h)}
+	} else {
+		md.UnverifiedBody = &checkReader{md, literalData.Body}
+	}
+
+	return md, nil
+}
+
+// checkReader reads the contents of the message's literal data packet. Once
+// that reaches EOF, it closes MessageDetails.decrypted, which checks the
+// OpenPGP MDC, and surfaces any failure as a read error rather than masking
+// it as a successful EOF.
+type checkReader struct {
+	md *MessageDetails
+	r  io.Reader
+}
+
+func (cr *checkReader) Read(buf []byte) (n int, err os.Error) {
+	n, err = cr.r.Read(buf)
+	if err == os.EOF {
+		if mdcErr := cr.md.decrypted.Close(); mdcErr != nil {
+			return n, mdcErr
+		}
+	}
+	return
+}
+
+// signatureCheckReader is like checkReader but additionally feeds everything
+// that is read into a running hash, so that once the literal data has been
+// completely read, the trailing Signature packet can be checked against it.
+type signatureCheckReader struct {
+	md *MessageDetails
+	h  hash.Hash
+	r  io.Reader
+}
+
+func (scr *signatureCheckReader) Read(buf []byte) (n int, err os.Error) {
+	n, err = scr.r.Read(buf)
+	if err == os.EOF {
+		p, sigErr := packet.Read(scr.md.packets)
+		if sigErr != nil {
+			return n, sigErr
+		}
+		sig, ok := p.(*packet.Signature)
+		if !ok {
+			return n, error.StructuralError("expected a signature packet after signed literal data")
+		}
+
+		if scr.md.SignedBy != nil {
+			scr.md.SignatureError = sig.Verify(scr.h, scr.md.SignedBy.PublicKey)
+		} else {
+			scr.md.SignatureError = error.UnknownIssuerError
+		}
+
+		if mdcErr := scr.md.decrypted.Close(); mdcErr != nil {
+			return n, mdcErr
+		}
+	}
+	return
+}
@@ -0,0 +1,101 @@
+// Copyright 2011 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package openpgp
+
+import (
+	"bytes"
+	"crypto/openpgp/error"
+	"crypto/openpgp/packet"
+	"os"
+	"testing"
+)
+
+// rawPacket builds a single new-format OpenPGP packet with the given tag
+// and body. It only supports the short (< 192 byte) length encoding, which
+// is all that the tests in this file need.
+func rawPacket(tag byte, body []byte) []byte {
+	return append([]byte{0xc0 | tag, byte(len(body))}, body...)
+}
+
+func TestReadMessagePublicKeyEncryptedUnsupported(t *testing.T) {
+	// A tag-1 PublicKeyEncrypted packet (RFC 4880, section 5.1) followed
+	// by a minimal tag-18 SymmetricallyEncrypted (MDC) packet. There is
+	// no corresponding private key, so ReadMessage should report the
+	// documented UnsupportedError rather than failing at the packet
+	// layer with an opaque UnknownPacketTypeError.
+	pkeBody := []byte{
+		3,                      // version
+		1, 2, 3, 4, 5, 6, 7, 8, // key id
+		1,                      // RSA
+		0, 3, 0xe0,             // a 3-bit MPI, stored as a single byte
+	}
+	seBody := []byte{1} // MDC packet version
+
+	msg := append(rawPacket(1, pkeBody), rawPacket(18, seBody)...)
+
+	_, err := ReadMessage(bytes.NewBuffer(msg), nil, nil)
+	if err == nil {
+		t.Fatalf("expected an error for a public-key encrypted message")
+	}
+	if _, ok := err.(error.UnsupportedError); !ok {
+		t.Fatalf("got error of type %T (%s), want error.UnsupportedError", err, err)
+	}
+}
+
+// TestReadMessageSignedNilKeyringDoesNotPanic guards against a regression
+// where ReadMessage called keyring.KeysById on a signed message without
+// first checking that the caller actually supplied a keyring: a perfectly
+// ordinary passphrase-encrypted, signed message would crash a caller who
+// passed nil because they only cared about decryption.
+func TestReadMessageSignedNilKeyringDoesNotPanic(t *testing.T) {
+	passphrase := []byte("correct horse battery staple")
+
+	buf := bytes.NewBuffer(nil)
+	key, err := packet.SerializeSymmetricKeyEncrypted(buf, passphrase, packet.CipherAES128, nil)
+	if err != nil {
+		t.Fatalf("SerializeSymmetricKeyEncrypted: %s", err)
+	}
+
+	w, err := packet.SerializeSymmetricallyEncrypted(buf, packet.CipherAES128, key)
+	if err != nil {
+		t.Fatalf("SerializeSymmetricallyEncrypted: %s", err)
+	}
+
+	// A minimal tag-4 OnePassSignature packet: version 3, sig type
+	// binary, hash SHA-1, pubkey algo RSA, an arbitrary key id, isLast.
+	opsBody := []byte{3, 0, 2, 1, 1, 2, 3, 4, 5, 6, 7, 8, 1}
+	if _, err := w.Write(rawPacket(4, opsBody)); err != nil {
+		t.Fatalf("writing OnePassSignature packet: %s", err)
+	}
+
+	lw, err := packet.SerializeLiteral(w, true, "", 0)
+	if err != nil {
+		t.Fatalf("SerializeLiteral: %s", err)
+	}
+	if _, err := lw.Write([]byte("hello")); err != nil {
+		t.Fatalf("Write: %s", err)
+	}
+	if err := lw.Close(); err != nil {
+		t.Fatalf("Close (literal): %s", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close (symmetric): %s", err)
+	}
+
+	prompt := func(keys []Key, symmetric bool) ([]byte, os.Error) {
+		return passphrase, nil
+	}
+
+	md, err := ReadMessage(buf, nil, prompt)
+	if err != nil {
+		t.Fatalf("ReadMessage: %s", err)
+	}
+	if !md.IsSigned {
+		t.Fatalf("expected IsSigned to be true")
+	}
+	if md.SignedBy != nil {
+		t.Fatalf("expected SignedBy to be nil with a nil keyring")
+	}
+}
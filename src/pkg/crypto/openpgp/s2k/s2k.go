@@ -0,0 +1,262 @@
+// Copyright 2011 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package s2k implements the various OpenPGP string-to-key transforms as
+// specified in RFC 4880, section 3.7.1.
+package s2k
+
+import (
+	"crypto"
+	"crypto/md5"
+	"crypto/openpgp/error"
+	"crypto/sha1"
+	"crypto/sha256"
+	"hash"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+)
+
+// Simple writes to out the result of computing the Simple S2K function (RFC
+// 4880, section 3.7.1.1) using the given hash and input passphrase.
+func Simple(h func() hash.Hash, out, in []byte) {
+	Salted(h, out, in, nil)
+}
+
+// Salted writes to out the result of computing the Salted S2K function (RFC
+// 4880, section 3.7.1.2) using the given hash, input passphrase and salt.
+func Salted(h func() hash.Hash, out, in []byte, salt []byte) {
+	hashLoop(h, out, in, salt, 0, false)
+}
+
+// Iterated writes to out the result of computing the Iterated and Salted S2K
+// function (RFC 4880, section 3.7.1.3) using the given hash, input
+// passphrase, salt and iteration count.
+func Iterated(h func() hash.Hash, out, in []byte, salt []byte, count int) {
+	hashLoop(h, out, in, salt, count, true)
+}
+
+// hashLoop hashes copies of salt||in, each prefixed with an increasing
+// number of zero bytes, until len(out) bytes of key material have been
+// produced. When iterated is true, salt||in is repeated until count bytes
+// have been hashed for each output block, as specified for the Iterated and
+// Salted S2K function.
+func hashLoop(h func() hash.Hash, out, in, salt []byte, count int, iterated bool) {
+	combined := make([]byte, len(salt)+len(in))
+	copy(combined, salt)
+	copy(combined[len(salt):], in)
+
+	if iterated && count < len(combined) {
+		count = len(combined)
+	}
+
+	digest := h()
+	var zeros []byte
+	for done := 0; done < len(out); {
+		digest.Reset()
+		digest.Write(zeros)
+		zeros = append(zeros, 0)
+
+		if iterated {
+			for written := 0; written < count; {
+				n := count - written
+				if n > len(combined) {
+					n = len(combined)
+				}
+				digest.Write(combined[:n])
+				written += n
+			}
+		} else {
+			digest.Write(combined)
+		}
+
+		done += copy(out[done:], digest.Sum())
+	}
+}
+
+// Parse reads a binary specification for a string-to-key transformation from
+// r and returns a function which performs that transform.
+func Parse(r io.Reader) (f func(out, in []byte), err os.Error) {
+	var buf [9]byte
+
+	if _, err = io.ReadFull(r, buf[:1]); err != nil {
+		return
+	}
+
+	switch buf[0] {
+	case 0:
+		if _, err = io.ReadFull(r, buf[:1]); err != nil {
+			return
+		}
+		h, ok := HashIdToHash(buf[0])
+		if !ok {
+			return nil, error.UnsupportedError("hash for S2K function: " + strconv.Itoa(int(buf[0])))
+		}
+		f = func(out, in []byte) { Simple(h, out, in) }
+		return
+	case 1:
+		if _, err = io.ReadFull(r, buf[:9]); err != nil {
+			return
+		}
+		h, ok := HashIdToHash(buf[0])
+		if !ok {
+			return nil, error.UnsupportedError("hash for S2K function: " + strconv.Itoa(int(buf[0])))
+		}
+		salt := append([]byte{}, buf[1:9]...)
+		f = func(out, in []byte) { Salted(h, out, in, salt) }
+		return
+	case 3:
+		if _, err = io.ReadFull(r, buf[:9]); err != nil {
+			return
+		}
+		h, ok := HashIdToHash(buf[0])
+		if !ok {
+			return nil, error.UnsupportedError("hash for S2K function: " + strconv.Itoa(int(buf[0])))
+		}
+		salt := append([]byte{}, buf[1:9]...)
+		if _, err = io.ReadFull(r, buf[:1]); err != nil {
+			return
+		}
+		count := decodeCount(buf[0])
+		f = func(out, in []byte) { Iterated(h, out, in, salt, count) }
+		return
+	}
+
+	return nil, error.UnsupportedError("S2K function type: " + strconv.Itoa(int(buf[0])))
+}
+
+// decodeCount returns the s2k mode 3 iterative count byte decoded as an
+// integer count as specified in RFC 4880, section 3.7.1.3.
+func decodeCount(c uint8) int {
+	return (16 + int(c&15)) << (uint(c>>4) + 6)
+}
+
+// encodeCount converts an iterative "count" as specified in RFC 4880,
+// section 3.7.1.3 into an encoded count byte. The value returned is the
+// smallest encoded count that is not smaller than count.
+func encodeCount(count int) uint8 {
+	for c := 0; c < 256; c++ {
+		if decodeCount(uint8(c)) >= count {
+			return uint8(c)
+		}
+	}
+	return 255
+}
+
+// HashIdToHash returns a hash.Hash constructor for the given hash id, as
+// specified in RFC 4880, section 9.4, along with ok=true. If the hash id is
+// not supported, ok is false.
+func HashIdToHash(id byte) (h func() hash.Hash, ok bool) {
+	switch id {
+	case 1:
+		return md5.New, true
+	case 2:
+		return sha1.New, true
+	case 8:
+		return sha256.New, true
+	}
+	return nil, false
+}
+
+// HashIdToCryptoHash returns the crypto.Hash that identifies the same hash
+// function as the given RFC 4880, section 9.4 hash id, along with ok=true.
+// This is distinct from the hash id itself, which rsa.SignPKCS1v15 and
+// rsa.VerifyPKCS1v15 do not accept. If the hash id is not supported, ok is
+// false.
+func HashIdToCryptoHash(id byte) (h crypto.Hash, ok bool) {
+	switch id {
+	case 1:
+		return crypto.MD5, true
+	case 2:
+		return crypto.SHA1, true
+	case 8:
+		return crypto.SHA256, true
+	}
+	return 0, false
+}
+
+// HashToHashId returns an RFC 4880 hash id for the given hash function, along
+// with ok=true. If the hash function is not supported, ok is false.
+func HashToHashId(h func() hash.Hash) (id byte, ok bool) {
+	switch {
+	case hashesEqual(h, md5.New):
+		return 1, true
+	case hashesEqual(h, sha1.New):
+		return 2, true
+	case hashesEqual(h, sha256.New):
+		return 8, true
+	}
+	return 0, false
+}
+
+// hashesEqual reports whether two hash constructors produce the same
+// concrete hash.Hash implementation.
+func hashesEqual(a, b func() hash.Hash) bool {
+	return reflect.TypeOf(a()) == reflect.TypeOf(b())
+}
+
+// Config collects configuration parameters for S2K key derivation,
+// letting a caller of Serialize override its defaults. A nil *Config is
+// equivalent to an empty one; zero fields fall back to their defaults.
+type Config struct {
+	// Hash is the hash function to be used. If nil, SHA-1 is used.
+	Hash func() hash.Hash
+	// S2KCount is the Iterated and Salted S2K hash iteration count, as
+	// described in RFC 4880, section 3.7.1.3. If zero, the default of
+	// 65536 is used.
+	S2KCount int
+}
+
+// defaultS2KCount is the default, hashed-until-this-many-bytes iteration
+// count used when a Config does not specify one. See RFC 4880, section
+// 3.7.1.3.
+const defaultS2KCount = 65536
+
+func (c *Config) hash() func() hash.Hash {
+	if c == nil || c.Hash == nil {
+		return sha1.New
+	}
+	return c.Hash
+}
+
+func (c *Config) count() int {
+	if c == nil || c.S2KCount == 0 {
+		return defaultS2KCount
+	}
+	return c.S2KCount
+}
+
+// Serialize writes an S2K specifier to w and derives a key of len(key)
+// bytes from passphrase into key, performing the Iterated and Salted S2K
+// function with a freshly generated, random salt. config may be nil, in
+// which case sensible defaults (SHA-1, 65536 iterations) are used.
+func Serialize(w io.Writer, key []byte, rand io.Reader, passphrase []byte, config *Config) (err os.Error) {
+	h := config.hash()
+	count := config.count()
+
+	hashId, ok := HashToHashId(h)
+	if !ok {
+		return error.UnsupportedError("no hash id found for hash function")
+	}
+
+	salt := make([]byte, 8)
+	if _, err = io.ReadFull(rand, salt); err != nil {
+		return
+	}
+
+	encodedCount := encodeCount(count)
+	if _, err = w.Write([]byte{3 /* iterated and salted */, hashId}); err != nil {
+		return
+	}
+	if _, err = w.Write(salt); err != nil {
+		return
+	}
+	if _, err = w.Write([]byte{encodedCount}); err != nil {
+		return
+	}
+
+	Iterated(h, key, passphrase, salt, decodeCount(encodedCount))
+	return nil
+}
@@ -0,0 +1,57 @@
+// Copyright 2011 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package s2k
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"testing"
+)
+
+func TestSerializeParseRoundTrip(t *testing.T) {
+	passphrase := []byte("correct horse battery staple")
+
+	buf := bytes.NewBuffer(nil)
+	key := make([]byte, 16)
+	if err := Serialize(buf, key, rand.Reader, passphrase, nil); err != nil {
+		t.Fatalf("Serialize: %s", err)
+	}
+
+	f, err := Parse(buf)
+	if err != nil {
+		t.Fatalf("Parse: %s", err)
+	}
+
+	key2 := make([]byte, 16)
+	f(key2, passphrase)
+
+	if !bytes.Equal(key, key2) {
+		t.Fatalf("derived keys differ: %x vs %x", key, key2)
+	}
+}
+
+func TestSerializeParseRoundTripWithConfig(t *testing.T) {
+	passphrase := []byte("correct horse battery staple")
+	config := &Config{Hash: sha256.New, S2KCount: 1024}
+
+	buf := bytes.NewBuffer(nil)
+	key := make([]byte, 32)
+	if err := Serialize(buf, key, rand.Reader, passphrase, config); err != nil {
+		t.Fatalf("Serialize: %s", err)
+	}
+
+	f, err := Parse(buf)
+	if err != nil {
+		t.Fatalf("Parse: %s", err)
+	}
+
+	key2 := make([]byte, 32)
+	f(key2, passphrase)
+
+	if !bytes.Equal(key, key2) {
+		t.Fatalf("derived keys differ: %x vs %x", key, key2)
+	}
+}